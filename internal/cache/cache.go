@@ -0,0 +1,116 @@
+// Package cache provides a small on-disk cache of episode sizes keyed by
+// PlayURL, so repeat runs of `list` don't have to re-HEAD every recording.
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a cached size is trusted before it is revalidated.
+const DefaultTTL = 30 * 24 * time.Hour
+
+// Entry is one cached episode size.
+type Entry struct {
+	ContentLength int64     `json:"content_length"`
+	FetchedAt     time.Time `json:"fetched_at"`
+}
+
+// Cache is a PlayURL -> Entry map persisted as JSON. It is safe for
+// concurrent use.
+type Cache struct {
+	path    string
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// DefaultPath returns the path to the cache file under the user's cache
+// directory (honoring $XDG_CACHE_HOME on Linux), e.g.
+// ~/.cache/hdhr-cli/sizes.json.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hdhr-cli", "sizes.json"), nil
+}
+
+// New returns an empty cache that will persist to path.
+func New(path string, ttl time.Duration) *Cache {
+	return &Cache{path: path, ttl: ttl, entries: make(map[string]Entry)}
+}
+
+// Load reads the cache file at path, if it exists. A missing file is not an
+// error; it just yields an empty cache.
+func Load(path string, ttl time.Duration) (*Cache, error) {
+	c := New(path, ttl)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the cached size for playURL, if present and not expired.
+func (c *Cache) Get(playURL string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[playURL]
+	if !ok {
+		return 0, false
+	}
+	if c.ttl > 0 && time.Since(entry.FetchedAt) > c.ttl {
+		return 0, false
+	}
+	return entry.ContentLength, true
+}
+
+// Set records the size for playURL as of now.
+func (c *Cache) Set(playURL string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[playURL] = Entry{ContentLength: size, FetchedAt: time.Now()}
+}
+
+// Prune removes entries whose PlayURL is not in keep, so the cache doesn't
+// grow unbounded with episodes that have since been deleted.
+func (c *Cache) Prune(keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for playURL := range c.entries {
+		if !keep[playURL] {
+			delete(c.entries, playURL)
+		}
+	}
+}
+
+// Save writes the cache to its path, creating the parent directory if
+// necessary.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, data, 0o644)
+}
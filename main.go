@@ -1,18 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"slices"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
+
+	"github.com/surferjeff/HDHomerunSweeper/internal/cache"
+	"github.com/surferjeff/HDHomerunSweeper/pkg/discovery"
 )
 
+// udpDiscoveryTimeout bounds how long UDP discovery waits for device
+// replies before giving up.
+const udpDiscoveryTimeout = 3 * time.Second
+
+// defaultConcurrency is the number of episodes fetched in parallel when
+// aggregating stats, unless overridden with --concurrency.
+const defaultConcurrency = 16
+
 // DiscoveryResult maps the JSON from SiliconDust's discovery API
 type DiscoveryResult struct {
 	DeviceID   string
@@ -31,10 +49,17 @@ type Recording struct {
 }
 
 type SeriesStat struct {
+	SeriesID     string
 	Title        string
 	Count        uint32
 	TotalSize    int64
 	EpisodesURLs []string
+	Episodes     []Episode
+	// EpisodeSizes holds the byte size fetched for the episode at the same
+	// index in Episodes; the two slices always grow together.
+	EpisodeSizes []int64
+
+	mu sync.Mutex
 }
 
 func main() {
@@ -48,14 +73,34 @@ func main() {
 	deleteSeriesCommand := flag.NewFlagSet("delete-series", flag.ExitOnError)
 
 	// Flags for subcommands
+	listConcurrency := listCommand.Int("concurrency", defaultConcurrency, "Number of episodes to fetch in parallel.")
+	listRefresh := listCommand.Bool("refresh", false, "Ignore the on-disk size cache and revalidate every episode.")
 	deleteSeriesTitle := deleteSeriesCommand.String("title", "", "A unique prefix of the title of the series to delete (required)")
 	deleteSeriesForever := deleteSeriesCommand.Bool("forever", false, "Never attempt to rerecord the episodes being deleted.")
+	deleteSeriesYes := deleteSeriesCommand.Bool("yes", false, "Skip the confirmation prompt.")
+	deleteSeriesDryRun := deleteSeriesCommand.Bool("dry-run", false, "Only print what would be deleted, without deleting anything.")
+	deleteSeriesConcurrency := deleteSeriesCommand.Int("concurrency", defaultConcurrency, "Number of episodes to fetch in parallel.")
+	deleteSeriesRefresh := deleteSeriesCommand.Bool("refresh", false, "Ignore the on-disk size cache and revalidate every episode.")
+	listDevice := listCommand.String("device", "", "Device ID of the HDHomeRun to use, bypassing discovery.")
+	listStorageUrl := listCommand.String("storage-url", "", "StorageURL of the HDHomeRun DVR engine, bypassing discovery entirely.")
+	deleteSeriesDevice := deleteSeriesCommand.String("device", "", "Device ID of the HDHomeRun to use, bypassing discovery.")
+	deleteSeriesStorageUrl := deleteSeriesCommand.String("storage-url", "", "StorageURL of the HDHomeRun DVR engine, bypassing discovery entirely.")
+	listTimeout := listCommand.Duration("timeout", 0, "Abort the whole command if it runs longer than this (e.g. 30s). 0 means no timeout.")
+	deleteSeriesTimeout := deleteSeriesCommand.Duration("timeout", 0, "Abort the whole command if it runs longer than this (e.g. 30s). 0 means no timeout.")
+	listOutput := listCommand.String("output", "table", "Output format: table, json, or csv.")
+
+	episodeCache := openEpisodeCache()
 
 	switch os.Args[1] {
 	case "list":
 		listCommand.Parse(os.Args[2:])
-		storageUrl := getStorageUrlOrExit()
-		listRecordings(storageUrl)
+		ctx, cancel := rootContext(*listTimeout)
+		defer cancel()
+		storageUrl := getStorageUrlOrExit(ctx, *listDevice, *listStorageUrl)
+		if err := listRecordings(ctx, storageUrl, *listConcurrency, episodeCache, *listRefresh, *listOutput); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 
 	case "delete-series":
 		deleteSeriesCommand.Parse(os.Args[2:])
@@ -63,7 +108,12 @@ func main() {
 			deleteSeriesCommand.PrintDefaults()
 			os.Exit(1)
 		}
-		deleteSeries(*deleteSeriesTitle, *deleteSeriesForever)
+		ctx, cancel := rootContext(*deleteSeriesTimeout)
+		defer cancel()
+		if err := deleteSeries(ctx, *deleteSeriesTitle, *deleteSeriesForever, *deleteSeriesYes, *deleteSeriesDryRun, *deleteSeriesConcurrency, episodeCache, *deleteSeriesRefresh, *deleteSeriesDevice, *deleteSeriesStorageUrl); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 
 	default:
 		fmt.Println("Unknown command.")
@@ -72,65 +122,237 @@ func main() {
 	}
 }
 
-func deleteSeries(title string, forever bool) {
-	storageUrl := getStorageUrlOrExit()
+// rootContext builds the context threaded through every network call: it is
+// cancelled on Ctrl-C, and additionally bounded by timeout if it is
+// non-zero.
+func rootContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
 
-	recordings, err := fetchRecordings(storageUrl)
+// asCancelledErr turns a context cancellation/deadline error into a clearer
+// message for the user, and passes any other error through unchanged.
+func asCancelledErr(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return errors.New("cancelled")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("timed out")
+	}
+	return err
+}
+
+// openEpisodeCache loads the on-disk episode size cache, falling back to an
+// unpersisted in-memory cache if the cache directory can't be determined or
+// the cache file can't be read.
+func openEpisodeCache() *cache.Cache {
+	path, err := cache.DefaultPath()
+	if err != nil {
+		fmt.Printf("Warning: could not locate cache directory, sizes won't be cached: %v\n", err)
+		return cache.New("", cache.DefaultTTL)
+	}
+
+	episodeCache, err := cache.Load(path, cache.DefaultTTL)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		fmt.Printf("Warning: could not read size cache %s, starting fresh: %v\n", path, err)
+		return cache.New(path, cache.DefaultTTL)
+	}
+	return episodeCache
+}
+
+func deleteSeries(ctx context.Context, title string, forever bool, skipConfirm bool, dryRun bool, concurrency int, episodeCache *cache.Cache, refresh bool, device string, storageUrlOverride string) error {
+	storageUrl := getStorageUrlOrExit(ctx, device, storageUrlOverride)
+
+	recordings, err := fetchRecordings(ctx, storageUrl)
+	if err != nil {
+		return asCancelledErr(err)
 	}
 
 	var deleteRecordings []Recording
 	for _, recording := range recordings {
 		if strings.HasPrefix(recording.Title, title) {
-			if len(deleteRecordings) > 0 {
-				fmt.Printf("More than one title matches '%s':\n%s\n%s\n",
-					title, deleteRecordings[0].Title, recording.Title)
-				return
-			}
 			deleteRecordings = append(deleteRecordings, recording)
 		}
 	}
 
 	if 0 == len(deleteRecordings) {
-		fmt.Printf("Nothing matches '%s'\n", title)
-		return
+		return fmt.Errorf("nothing matches '%s'", title)
 	}
 
-	seriesMap := collectRecordings(recordings)
+	seriesMap := collectRecordings(deleteRecordings)
+	if len(seriesMap) > 1 {
+		var titles []string
+		for _, s := range seriesMap {
+			titles = append(titles, s.Title)
+		}
+		return fmt.Errorf("more than one title matches '%s':\n%s", title, strings.Join(titles, "\n"))
+	}
+
+	var stat *SeriesStat
+	for _, s := range seriesMap {
+		stat = s
+	}
+	if err := aggregateStats(ctx, []*SeriesStat{stat}, concurrency, episodeCache, refresh, false); err != nil {
+		return asCancelledErr(err)
+	}
+
+	fmt.Printf("Series: %s\n", stat.Title)
+	fmt.Printf("Episodes: %d\n", stat.Count)
+	fmt.Printf("Storage to reclaim: %s\n", humanizeSize(stat.TotalSize))
+
+	if dryRun {
+		fmt.Println("Dry run: no episodes were deleted.")
+		return nil
+	}
+
+	if !skipConfirm {
+		fmt.Printf("Type the series title to confirm deletion (%s): ", stat.Title)
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if strings.TrimSpace(response) != stat.Title {
+			return fmt.Errorf("confirmation did not match series title, aborting")
+		}
+	}
+
+	var deleteErrors []error
+	for _, episode := range stat.Episodes {
+		if err := ctx.Err(); err != nil {
+			return asCancelledErr(err)
+		}
+		if err := deleteEpisode(ctx, episode, forever); err != nil {
+			deleteErrors = append(deleteErrors, fmt.Errorf("%s: %w", episode.PlayURL, err))
+		}
+	}
 
-	oneMap := make(map[string]*SeriesStat)
-	for key, stat := range seriesMap {
-		oneMap[key] = stat
-		aggregateStats(stat)
+	if len(deleteErrors) > 0 {
+		fmt.Printf("Failed to delete %d of %d episodes:\n", len(deleteErrors), len(stat.Episodes))
+		for _, err := range deleteErrors {
+			fmt.Println(" -", err)
+		}
+		return fmt.Errorf("%d episode(s) failed to delete", len(deleteErrors))
 	}
 
+	fmt.Printf("Deleted %d episodes.\n", len(stat.Episodes))
+	return nil
+}
+
+// deleteEpisode issues the delete command for a single episode using its CmdURL.
+// rerecord is set to 0 when forever is true (never rerecord), 1 otherwise.
+func deleteEpisode(ctx context.Context, episode Episode, forever bool) error {
+	cmdUrl, err := url.Parse(episode.CmdURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse command URL: %w", err)
+	}
+
+	rerecord := "1"
+	if forever {
+		rerecord = "0"
+	}
+
+	query := cmdUrl.Query()
+	query.Set("cmd", "delete")
+	query.Set("rerecord", rerecord)
+	cmdUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cmdUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post delete command: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("delete command returned status %d", resp.StatusCode)
+	}
+
+	return nil
 }
 
 func printUsage() {
 	fmt.Println("HDHomeRun DVR CLI")
 	fmt.Println("Usage:")
-	fmt.Println("  hdhr-cli list")
-	fmt.Println("  hdhr-cli delete-series --title \"Series Title\"  --forever")
+	fmt.Println("  hdhr-cli list [--concurrency N] [--refresh] [--device ID] [--storage-url URL] [--timeout DURATION] [--output table|json|csv]")
+	fmt.Println("  hdhr-cli delete-series --title \"Series Title\" --forever [--yes] [--dry-run] [--concurrency N] [--refresh] [--device ID] [--storage-url URL] [--timeout DURATION]")
 }
 
 // getStorageUrlOrExit wraps the discovery logic and terminates if it fails
-func getStorageUrlOrExit() string {
-	ip, err := getStorageUrl()
+func getStorageUrlOrExit(ctx context.Context, device string, storageUrlOverride string) string {
+	storageUrl, err := getStorageUrl(ctx, device, storageUrlOverride)
 	if err != nil {
-		fmt.Printf("Discovery Error: %v\n", err)
+		fmt.Printf("Discovery Error: %v\n", asCancelledErr(err))
 		os.Exit(1)
 	}
-	return ip
+	return storageUrl
 }
 
-// getStorageUrl queries the SiliconDust cloud API to find the local IP
-func getStorageUrl() (string, error) {
+// getStorageUrl resolves the DVR engine's StorageURL. storageUrlOverride, if
+// set, bypasses discovery entirely. Otherwise, as long as the caller didn't
+// ask for a specific device, a previously cached URL is used if available;
+// then the hdhomerun.local mDNS/cloud lookup is tried, falling back to
+// HDHomeRun's UDP local discovery protocol (restricted to a single device
+// ID) if that fails. The discovery cache is keyed on the last device
+// discovered, not a specific ID, so it is skipped whenever --device is set
+// to keep that flag meaningful on every invocation. mDNS/cloud discovery
+// has no way to filter by device ID either, so it is skipped in favor of
+// going straight to UDP discovery whenever --device is set.
+func getStorageUrl(ctx context.Context, device string, storageUrlOverride string) (string, error) {
+	if storageUrlOverride != "" {
+		return storageUrlOverride, nil
+	}
+
+	if device == "" {
+		if cached, ok := discovery.LoadCachedStorageURL(); ok {
+			return cached, nil
+		}
+	}
+
 	fmt.Println("Searching for HDHomeRun devices on the local network...")
 
+	var storageUrl string
+	var err error
+	if device != "" {
+		fmt.Println("--device is set, which mDNS/cloud discovery can't filter by; going straight to UDP broadcast discovery...")
+		storageUrl, err = getStorageUrlViaUDP(ctx, device)
+	} else if storageUrl, err = getStorageUrlViaMDNS(ctx); err != nil {
+		fmt.Printf("mDNS discovery failed (%v), falling back to UDP broadcast discovery...\n", err)
+		storageUrl, err = getStorageUrlViaUDP(ctx, device)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := discovery.SaveCachedStorageURL(storageUrl); err != nil {
+		fmt.Printf("Warning: could not cache discovered StorageURL: %v\n", err)
+	}
+	return storageUrl, nil
+}
+
+// getStorageUrlViaMDNS queries hdhomerun.local, which resolves via mDNS on
+// the local network or SiliconDust's cloud discovery broker.
+func getStorageUrlViaMDNS(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://hdhomerun.local/discover.json", nil)
+	if err != nil {
+		return "", err
+	}
+
 	client := http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get("http://hdhomerun.local/discover.json")
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to query discovery API: %w", err)
 	}
@@ -145,22 +367,51 @@ func getStorageUrl() (string, error) {
 		return "", err
 	}
 
-	var discovery DiscoveryResult
-	if err := json.Unmarshal(body, &discovery); err != nil {
+	var result DiscoveryResult
+	if err := json.Unmarshal(body, &result); err != nil {
 		return "", fmt.Errorf("failed to parse discovery JSON: %w", err)
 	}
 
-	return discovery.StorageURL, nil
+	return result.StorageURL, nil
+}
+
+// getStorageUrlViaUDP falls back to HDHomeRun's UDP local discovery
+// protocol when mDNS/cloud discovery is unavailable. If device is set, only
+// that device ID is considered.
+func getStorageUrlViaUDP(ctx context.Context, device string) (string, error) {
+	devices, err := discovery.Discover(ctx, udpDiscoveryTimeout)
+	if err != nil {
+		return "", fmt.Errorf("UDP discovery failed: %w", err)
+	}
+
+	for _, d := range devices {
+		if device != "" && !strings.EqualFold(d.DeviceID, device) {
+			continue
+		}
+		storageUrl, err := discovery.StorageURL(ctx, d.BaseURL)
+		if err != nil {
+			continue
+		}
+		return storageUrl, nil
+	}
+
+	return "", fmt.Errorf("no HDHomeRun devices found via UDP discovery")
 }
 
 type Episode struct {
-	PlayURL string
-	CmdURL  string
+	PlayURL   string
+	CmdURL    string
+	StartTime int64
 }
 
 // fetchRecordings gets the JSON array of recordings from the device
-func fetchRecordings(recordingsUrl string) ([]Recording, error) {
-	resp, err := http.Get(recordingsUrl)
+func fetchRecordings(ctx context.Context, recordingsUrl string) ([]Recording, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, recordingsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to HDHomeRun DVR engine: %w", err)
 	}
@@ -183,31 +434,29 @@ func fetchRecordings(recordingsUrl string) ([]Recording, error) {
 	return recordings, nil
 }
 
-func listRecordings(storageUrl string) {
-	recordings, err := fetchRecordings(storageUrl)
+func listRecordings(ctx context.Context, storageUrl string, concurrency int, episodeCache *cache.Cache, refresh bool, output string) error {
+	recordings, err := fetchRecordings(ctx, storageUrl)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return asCancelledErr(err)
 	}
 
 	seriesMap := collectRecordings(recordings)
 
-	oneMap := make(map[string]*SeriesStat)
-	for key, stat := range seriesMap {
-		oneMap[key] = stat
-		aggregateStats(stat)
-	}
-	printSeriesMap(oneMap)
-}
-
-func printSeriesMap(seriesMap map[string]*SeriesStat) {
-	// 1. Convert the map values into a slice so we can sort them
 	stats := make([]*SeriesStat, 0, len(seriesMap))
 	for _, stat := range seriesMap {
 		stats = append(stats, stat)
 	}
+	if err := aggregateStats(ctx, stats, concurrency, episodeCache, refresh, true); err != nil {
+		return asCancelledErr(err)
+	}
+	return printSeriesMap(stats, output)
+}
 
-	// 2. Sort the slice by TotalSize in descending order
+// printSeriesMap renders stats in the requested output format: "table" (the
+// default, human-readable), "json", or "csv".
+func printSeriesMap(stats []*SeriesStat, output string) error {
+	// Sort by TotalSize in descending order.
+	stats = slices.Clone(stats)
 	slices.SortFunc(stats, func(a, b *SeriesStat) int {
 		if a.TotalSize > b.TotalSize {
 			return 1
@@ -217,18 +466,129 @@ func printSeriesMap(seriesMap map[string]*SeriesStat) {
 		return 0
 	})
 
+	switch output {
+	case "table", "":
+		printSeriesTable(stats)
+		return nil
+	case "json":
+		return printSeriesJSON(stats)
+	case "csv":
+		return printSeriesCSV(stats)
+	default:
+		return fmt.Errorf("unknown --output %q, want table, json, or csv", output)
+	}
+}
+
+func printSeriesTable(stats []*SeriesStat) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 	fmt.Fprintln(w, "SERIES TITLE\tEPISODES\tSTORAGE USED\t")
 	fmt.Fprintln(w, "------------\t--------\t------------\t")
 
-	// 3. Iterate over the sorted slice instead of the map
 	for _, stat := range stats {
-		sizeGB := float64(stat.TotalSize) / (1024 * 1024 * 1024)
-		fmt.Fprintf(w, "%s\t%d\t%.2f GB\t\n", stat.Title, stat.Count, sizeGB)
+		fmt.Fprintf(w, "%s\t%d\t%10s\t\n", stat.Title, stat.Count, humanizeSize(stat.TotalSize))
+	}
+	w.Flush()
+
+	fmt.Printf("\nTotal Series Found: %d\n", len(stats))
+}
+
+// episodeOutput is the JSON/CSV representation of a single episode.
+type episodeOutput struct {
+	PlayURL   string `json:"play_url"`
+	Bytes     int64  `json:"bytes"`
+	StartTime int64  `json:"start_time"`
+}
+
+// seriesOutput is the JSON representation of a single series and its
+// episodes.
+type seriesOutput struct {
+	SeriesID     string          `json:"series_id"`
+	Title        string          `json:"title"`
+	EpisodeCount uint32          `json:"episode_count"`
+	TotalBytes   int64           `json:"total_bytes"`
+	Episodes     []episodeOutput `json:"episodes"`
+}
+
+func toSeriesOutputs(stats []*SeriesStat) []seriesOutput {
+	out := make([]seriesOutput, len(stats))
+	for i, stat := range stats {
+		episodes := make([]episodeOutput, len(stat.Episodes))
+		for j, episode := range stat.Episodes {
+			episodes[j] = episodeOutput{
+				PlayURL:   episode.PlayURL,
+				Bytes:     stat.EpisodeSizes[j],
+				StartTime: episode.StartTime,
+			}
+		}
+		out[i] = seriesOutput{
+			SeriesID:     stat.SeriesID,
+			Title:        stat.Title,
+			EpisodeCount: stat.Count,
+			TotalBytes:   stat.TotalSize,
+			Episodes:     episodes,
+		}
+	}
+	return out
+}
+
+func printSeriesJSON(stats []*SeriesStat) error {
+	out := toSeriesOutputs(stats)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func printSeriesCSV(stats []*SeriesStat) error {
+	w := csv.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	header := []string{"series_id", "title", "episode_count", "total_bytes", "play_url", "bytes", "start_time"}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, stat := range stats {
+		if len(stat.Episodes) == 0 {
+			row := []string{stat.SeriesID, stat.Title, fmt.Sprint(stat.Count), fmt.Sprint(stat.TotalSize), "", "", ""}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+		for j, episode := range stat.Episodes {
+			row := []string{
+				stat.SeriesID,
+				stat.Title,
+				fmt.Sprint(stat.Count),
+				fmt.Sprint(stat.TotalSize),
+				episode.PlayURL,
+				fmt.Sprint(stat.EpisodeSizes[j]),
+				fmt.Sprint(episode.StartTime),
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
 	}
+
 	w.Flush()
+	return w.Error()
+}
 
-	fmt.Printf("\nTotal Series Found: %d\n", len(seriesMap))
+// humanizeSize formats bytes using the largest unit (KB/MB/GB/TB) that
+// keeps the number readable, right-padded to line up in table output.
+func humanizeSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGT"[exp])
 }
 
 func collectRecordings(recordings []Recording) map[string]*SeriesStat {
@@ -237,7 +597,8 @@ func collectRecordings(recordings []Recording) map[string]*SeriesStat {
 		series, exists := seriesMap[rec.SeriesID]
 		if !exists {
 			series = &SeriesStat{
-				Title: rec.Title,
+				SeriesID: rec.SeriesID,
+				Title:    rec.Title,
 			}
 			seriesMap[rec.SeriesID] = series
 		}
@@ -248,30 +609,173 @@ func collectRecordings(recordings []Recording) map[string]*SeriesStat {
 	return seriesMap
 }
 
-func aggregateStats(stat *SeriesStat) error {
-	stat.Count = 0
-	stat.TotalSize = 0
-	for _, url := range stat.EpisodesURLs {
-		episodes, err := getEpisodes(url)
-		if err != nil {
-			return err
+// episodeJob is one unit of work for the aggregation worker pool: fetch the
+// episode(s) behind a single recording's EpisodesURL and HEAD each one.
+type episodeJob struct {
+	stat *SeriesStat
+	url  string
+}
+
+// episodeJobResult is reported back by a worker once it has resolved every
+// episode (and size) for a single episodeJob, or the error it hit doing so.
+type episodeJobResult struct {
+	stat     *SeriesStat
+	episodes []Episode
+	sizes    []int64
+	err      error
+}
+
+// aggregateStats recomputes Count, TotalSize and Episodes for every stat by
+// fetching episode metadata and sizes over a bounded worker pool of
+// concurrency workers. Episode sizes are served from episodeCache when
+// possible (unless refresh is set), and the cache is saved before
+// aggregateStats returns. If prune is true, stats is assumed to cover every
+// series currently on the DVR, and cache entries for episodes outside that
+// set are evicted; callers that pass a partial stats slice (e.g. a single
+// matched series) must pass prune=false so they don't evict cache entries
+// for series they didn't look at. It stops launching new work and returns
+// the first error encountered, but lets in-flight requests drain. If ctx is
+// already cancelled or expired, either before this call or while no worker
+// happens to be mid-job, ctx.Err() is returned rather than a silent success
+// over zero episodes.
+func aggregateStats(ctx context.Context, stats []*SeriesStat, concurrency int, episodeCache *cache.Cache, refresh bool, prune bool) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	for _, stat := range stats {
+		stat.Count = 0
+		stat.TotalSize = 0
+		stat.Episodes = nil
+		stat.EpisodeSizes = nil
+	}
+
+	var jobs []episodeJob
+	for _, stat := range stats {
+		for _, url := range stat.EpisodesURLs {
+			jobs = append(jobs, episodeJob{stat: stat, url: url})
 		}
+	}
+	total := len(jobs)
+	if total == 0 {
+		return nil
+	}
 
-		for _, episode := range episodes {
-			size, err := getEpisodeSize(episode.PlayURL)
-			if err != nil {
-				return err
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: concurrency,
+		},
+	}
+
+	jobCh := make(chan episodeJob)
+	resultCh := make(chan episodeJobResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				resultCh <- runEpisodeJob(ctx, client, job, episodeCache, refresh)
 			}
-			stat.Count += 1
-			stat.TotalSize += size
+		}()
+	}
+
+	go func() {
+		defer close(jobCh)
+		for _, job := range jobs {
+			select {
+			case <-ctx.Done():
+				return
+			case jobCh <- job:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	var firstErr error
+	processed := 0
+	seen := make(map[string]bool)
+	for result := range resultCh {
+		processed++
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+				cancel()
+			}
+			continue
 		}
+
+		result.stat.mu.Lock()
+		result.stat.Count += uint32(len(result.episodes))
+		for _, size := range result.sizes {
+			result.stat.TotalSize += size
+		}
+		result.stat.Episodes = append(result.stat.Episodes, result.episodes...)
+		result.stat.EpisodeSizes = append(result.stat.EpisodeSizes, result.sizes...)
+		result.stat.mu.Unlock()
+
+		for _, episode := range result.episodes {
+			seen[episode.PlayURL] = true
+		}
+
+		fmt.Fprintf(os.Stderr, "\rprocessed %d/%d episodes", processed, total)
 	}
-	return nil
+	fmt.Fprintln(os.Stderr)
+
+	if prune {
+		episodeCache.Prune(seen)
+	}
+	if err := episodeCache.Save(); err != nil {
+		fmt.Printf("Warning: could not save size cache: %v\n", err)
+	}
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
 }
 
-func getEpisodes(url string) ([]Episode, error) {
-	client := http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+func runEpisodeJob(ctx context.Context, client *http.Client, job episodeJob, episodeCache *cache.Cache, refresh bool) episodeJobResult {
+	episodes, err := getEpisodes(ctx, client, job.url)
+	if err != nil {
+		return episodeJobResult{stat: job.stat, err: err}
+	}
+
+	sizes := make([]int64, len(episodes))
+	for i, episode := range episodes {
+		if !refresh {
+			if size, ok := episodeCache.Get(episode.PlayURL); ok {
+				sizes[i] = size
+				continue
+			}
+		}
+
+		size, err := getEpisodeSize(ctx, client, episode.PlayURL)
+		if err != nil {
+			return episodeJobResult{stat: job.stat, err: err}
+		}
+		sizes[i] = size
+		episodeCache.Set(episode.PlayURL, size)
+	}
+
+	return episodeJobResult{stat: job.stat, episodes: episodes, sizes: sizes}
+}
+
+func getEpisodes(ctx context.Context, client *http.Client, url string) ([]Episode, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch %v: %w", url, err)
 	}
@@ -293,9 +797,12 @@ func getEpisodes(url string) ([]Episode, error) {
 	return episodes, nil
 }
 
-func getEpisodeSize(playUrl string) (int64, error) {
-	client := http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Head(playUrl)
+func getEpisodeSize(ctx context.Context, client *http.Client, playUrl string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, playUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("Failed to fetch %v: %w", playUrl, err)
 	}
@@ -0,0 +1,241 @@
+// Package discovery implements the HDHomeRun UDP local discovery protocol,
+// used as a fallback when the hdhomerun.local mDNS/cloud broker lookup
+// fails (e.g. on networks without mDNS, or when SiliconDust's cloud is
+// down).
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	discoveryPort = 65001
+
+	packetTypeDiscoverReq = 0x0002
+	packetTypeDiscoverRpy = 0x0003
+
+	tagDeviceType = 0x01
+	tagDeviceID   = 0x02
+	tagBaseURL    = 0x2a
+
+	deviceTypeWildcard = 0xFFFFFFFF
+	deviceIDWildcard   = 0xFFFFFFFF
+)
+
+// Device is one HDHomeRun device found via UDP discovery.
+type Device struct {
+	DeviceID string
+	BaseURL  string
+}
+
+// Discover broadcasts a discovery request packet to 255.255.255.255:65001
+// and collects replies until timeout elapses or ctx is cancelled, whichever
+// comes first.
+func Discover(ctx context.Context, timeout time.Duration) ([]Device, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: 0})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	broadcastAddr := &net.UDPAddr{IP: net.IPv4bcast, Port: discoveryPort}
+	if _, err := conn.WriteToUDP(buildDiscoverRequest(), broadcastAddr); err != nil {
+		return nil, fmt.Errorf("failed to send discovery broadcast: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	stopCtx := make(chan struct{})
+	defer close(stopCtx)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stopCtx:
+		}
+	}()
+
+	var devices []Device
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return devices, ctx.Err()
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			}
+			return devices, fmt.Errorf("failed to read discovery reply: %w", err)
+		}
+		if device, ok := parseDiscoverReply(buf[:n]); ok {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// StorageURL queries a discovered device's discover.json endpoint to find
+// its DVR StorageURL.
+func StorageURL(ctx context.Context, baseURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/discover.json", nil)
+	if err != nil {
+		return "", err
+	}
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned status %d", baseURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		StorageURL string
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse discovery JSON: %w", err)
+	}
+	return result.StorageURL, nil
+}
+
+func buildDiscoverRequest() []byte {
+	var payload bytes.Buffer
+	writeTLV(&payload, tagDeviceType, deviceTypeWildcard)
+	writeTLV(&payload, tagDeviceID, deviceIDWildcard)
+	return buildPacket(packetTypeDiscoverReq, payload.Bytes())
+}
+
+func writeTLV(buf *bytes.Buffer, tag byte, value uint32) {
+	buf.WriteByte(tag)
+	buf.WriteByte(4)
+	binary.Write(buf, binary.BigEndian, value)
+}
+
+func buildPacket(packetType uint16, payload []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(packetType))
+	binary.Write(&buf, binary.BigEndian, uint16(len(payload)))
+	buf.Write(payload)
+	crc := crc32.ChecksumIEEE(buf.Bytes())
+	binary.Write(&buf, binary.LittleEndian, crc)
+	return buf.Bytes()
+}
+
+func parseDiscoverReply(data []byte) (Device, bool) {
+	if len(data) < 4 {
+		return Device{}, false
+	}
+	if binary.BigEndian.Uint16(data[0:2]) != packetTypeDiscoverRpy {
+		return Device{}, false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if 4+length > len(data) {
+		return Device{}, false
+	}
+	payload := data[4 : 4+length]
+
+	var device Device
+	for i := 0; i+2 <= len(payload); {
+		tag := payload[i]
+		tlvLen := int(payload[i+1])
+		i += 2
+		if i+tlvLen > len(payload) {
+			break
+		}
+		value := payload[i : i+tlvLen]
+		switch tag {
+		case tagDeviceID:
+			if tlvLen == 4 {
+				device.DeviceID = fmt.Sprintf("%08X", binary.BigEndian.Uint32(value))
+			}
+		case tagBaseURL:
+			device.BaseURL = string(value)
+		}
+		i += tlvLen
+	}
+
+	if device.BaseURL == "" {
+		return Device{}, false
+	}
+	return device, true
+}
+
+// cachedDiscoveryTTL is how long a cached StorageURL is trusted before
+// discovery is retried.
+const cachedDiscoveryTTL = 24 * time.Hour
+
+type cachedDiscovery struct {
+	StorageURL string    `json:"storage_url"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+func cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hdhr-cli", "discovery.json"), nil
+}
+
+// LoadCachedStorageURL returns the last discovered StorageURL, if it was
+// cached within cachedDiscoveryTTL.
+func LoadCachedStorageURL() (string, bool) {
+	path, err := cachePath()
+	if err != nil {
+		return "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	var cached cachedDiscovery
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return "", false
+	}
+	if cached.StorageURL == "" || time.Since(cached.FetchedAt) > cachedDiscoveryTTL {
+		return "", false
+	}
+	return cached.StorageURL, true
+}
+
+// SaveCachedStorageURL persists storageURL so future invocations can skip
+// the network round-trip.
+func SaveCachedStorageURL(storageURL string) error {
+	path, err := cachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cachedDiscovery{StorageURL: storageURL, FetchedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}